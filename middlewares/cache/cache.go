@@ -2,35 +2,181 @@
 package cache
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// HashAlgorithm selects the hashing algorithm used to compute an ETag
+type HashAlgorithm int
+
+const (
+	// FNV generates the ETag with a fast, non-cryptographic fnv-1a hash
+	FNV HashAlgorithm = iota
+	// SHA1 generates the ETag with a cryptographic sha1 hash
+	SHA1
+)
+
 // HTTPCache is a struct type for cache parameter
 type HTTPCache struct {
 	// MaxAge is value in seconds
 	MaxAge int
 	// Expires represents date and time in http format
 	Expires string
+	// Algorithm is the hashing algorithm used to compute the ETag
+	Algorithm HashAlgorithm
+	// Weak marks the generated ETag as a weak validator, W/"..."
+	Weak bool
+	// Buffer controls whether the response body is buffered so an ETag
+	// can be computed from it. Disabling it streams the response
+	// straight through and skips ETag/conditional-request handling
+	Buffer bool
 }
 
-// NeNeNewHTTPCache is a constructor for HTTPCache
+// NewHTTPCache is a constructor for HTTPCache
 func NewHTTPCache(month int) *HTTPCache {
 	t := time.Now().AddDate(0, month, 0)
 	return &HTTPCache{
-		MaxAge:  int(time.Until(t).Seconds()),
-		Expires: t.Format(http.TimeFormat),
+		MaxAge:    int(time.Until(t).Seconds()),
+		Expires:   t.Format(http.TimeFormat),
+		Algorithm: FNV,
+		Buffer:    true,
 	}
 }
 
-// Middleware is a net/http middleware for setting up
-// max-age and Expires cache parameters
+// NewHTTPCacheWithOptions is a constructor for HTTPCache that additionally
+// lets the caller pick the ETag hashing algorithm, whether the tag is weak
+// or strong, and whether the response body is buffered to compute it
+func NewHTTPCacheWithOptions(month int, algo HashAlgorithm, weak, buffer bool) *HTTPCache {
+	c := NewHTTPCache(month)
+	c.Algorithm = algo
+	c.Weak = weak
+	c.Buffer = buffer
+	return c
+}
+
+// recorder buffers a response so an ETag can be computed from the body
+// before anything reaches the underlying http.ResponseWriter
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   *bytes.Buffer
+}
+
+func newRecorder(w http.ResponseWriter) *recorder {
+	return &recorder{ResponseWriter: w, status: http.StatusOK, body: new(bytes.Buffer)}
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// Middleware is a net/http middleware for setting up max-age and Expires
+// cache parameters. When Buffer is enabled it also computes an ETag from
+// 200 response bodies and honors If-None-Match/If-Modified-Since requests
+// with a 304 Not Modified, turning the plain max-age/Expires decoration
+// into an HTTP/1.1 validator. Non-200 responses are never short-circuited
+// since they aren't a cacheable representation of the resource.
 func (c *HTTPCache) Middleware(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", c.MaxAge))
 		w.Header().Set("Expires", c.Expires)
-		next.ServeHTTP(w, r)
+		if !c.Buffer {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := newRecorder(w)
+		next.ServeHTTP(rec, r)
+		if rec.status == http.StatusOK {
+			etag := c.etag(rec.body.Bytes())
+			w.Header().Set("ETag", etag)
+			lastMod, hasLastMod := lastModified(w.Header())
+			if c.notModified(r, etag, lastMod, hasLastMod) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes()) //nolint:errcheck
 	}
 	return http.HandlerFunc(fn)
 }
+
+// lastModified parses the Last-Modified header set by the wrapped handler,
+// if any. There's no implicit notion of a resource's modification time,
+// so If-Modified-Since is only honored when the handler supplied one.
+func lastModified(h http.Header) (time.Time, bool) {
+	v := h.Get("Last-Modified")
+	if len(v) == 0 {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// etag computes a quoted ETag value from the given response body using
+// the configured hashing algorithm and strength
+func (c *HTTPCache) etag(body []byte) string {
+	var h hash.Hash
+	switch c.Algorithm {
+	case SHA1:
+		h = sha1.New()
+	default:
+		h = fnv.New64a()
+	}
+	h.Write(body) //nolint:errcheck
+	tag := strconv.Quote(fmt.Sprintf("%x", h.Sum(nil)))
+	if c.Weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// notModified reports whether the request's conditional headers indicate
+// the cached representation is still fresh, per RFC 7232. If-None-Match
+// takes precedence over If-Modified-Since when both are present.
+// If-Modified-Since can only be honored when the handler reported a
+// lastMod via its own Last-Modified header.
+func (c *HTTPCache) notModified(r *http.Request, etag string, lastMod time.Time, hasLastMod bool) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if !hasLastMod {
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastMod.After(t)
+		}
+	}
+	return false
+}
+
+// etagMatches reports whether etag is present in the comma separated list
+// of entity tags found in an If-None-Match header, ignoring the weak
+// validator prefix, or whether the header is the wildcard "*"
+func etagMatches(header, etag string) bool {
+	for _, tok := range strings.Split(header, ",") {
+		tok = strings.TrimSpace(tok)
+		if tok == "*" {
+			return true
+		}
+		if strings.TrimPrefix(tok, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
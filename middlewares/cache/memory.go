@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// memoryEntry pairs a cached value with its expiry time
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStorer is a Storer backed by an in-process LRU cache. It is the
+// default backend for Store and does not share state across replicas.
+type MemoryStorer struct {
+	mu    sync.RWMutex
+	cache *lru.Cache
+}
+
+// NewMemoryStorer returns a MemoryStorer holding at most maxEntries items,
+// evicting the least recently used entry once the limit is reached
+func NewMemoryStorer(maxEntries int) (*MemoryStorer, error) {
+	c, err := lru.New(maxEntries)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryStorer{cache: c}, nil
+}
+
+// Get implements Storer
+func (m *MemoryStorer) Get(key string) ([]byte, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.cache.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	entry, ok := v.(*memoryEntry)
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set implements Storer
+func (m *MemoryStorer) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache.Add(key, &memoryEntry{value: value, expires: time.Now().Add(ttl)})
+	return nil
+}
+
+// Purge implements Storer, evicting every key that matches the glob
+// pattern as understood by path.Match
+func (m *MemoryStorer) Purge(pattern string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.cache.Keys() {
+		key, ok := k.(string)
+		if !ok {
+			continue
+		}
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			return err
+		}
+		if matched {
+			m.cache.Remove(k)
+		}
+	}
+	return nil
+}
+
+// Delete implements Storer, evicting the entry stored under the exact
+// key, without any glob interpretation
+func (m *MemoryStorer) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache.Remove(key)
+	return nil
+}
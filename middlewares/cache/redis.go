@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStorer is a Storer backed by Redis, allowing the response cache to
+// be shared across multiple replicas of a service
+type RedisStorer struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisStorer is a constructor for RedisStorer
+func NewRedisStorer(client *redis.Client) *RedisStorer {
+	return &RedisStorer{client: client, ctx: context.Background()}
+}
+
+// Get implements Storer
+func (r *RedisStorer) Get(key string) ([]byte, bool, error) {
+	v, err := r.client.Get(r.ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// Set implements Storer
+func (r *RedisStorer) Set(key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(r.ctx, key, value, ttl).Err()
+}
+
+// Purge implements Storer, evicting every key that matches the glob
+// pattern understood by Redis' KEYS command
+func (r *RedisStorer) Purge(pattern string) error {
+	keys, err := r.client.Keys(r.ctx, pattern).Result()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(r.ctx, keys...).Err()
+}
+
+// Delete implements Storer, evicting the entry stored under the exact
+// key, without any glob interpretation
+func (r *RedisStorer) Delete(key string) error {
+	return r.client.Del(r.ctx, key).Err()
+}
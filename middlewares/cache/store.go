@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Storer is the pluggable backend used by Store to persist cached response
+// bodies. Implementations must be safe for concurrent use. Purge evicts
+// every entry whose key matches pattern, a glob pattern whose exact syntax
+// is the backend's own (path.Match(pattern, key) for MemoryStorer, the
+// KEYS command's glob for RedisStorer); cache keys routinely contain "[" and "]" (eg.
+// from filter[name]=... or fields[articles]=...), which are glob
+// metacharacters, so callers that know the exact key to evict should use
+// Delete instead of turning it into a Purge pattern.
+type Storer interface {
+	// Get returns the cached value for key, and whether it was found
+	Get(key string) ([]byte, bool, error)
+	// Set stores value under key with the given time-to-live
+	Set(key string, value []byte, ttl time.Duration) error
+	// Purge evicts every entry whose key matches pattern
+	Purge(pattern string) error
+	// Delete evicts the entry stored under the exact key, without any
+	// glob interpretation
+	Delete(key string) error
+}
+
+// cacheEntry is the value stored by Store for a single cached response
+type cacheEntry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store is a Vary-aware in-process response cache middleware. Unlike
+// HTTPCache, which only decorates responses with cache-control headers,
+// Store actually caches response bodies in a pluggable Storer, keyed by
+// method, URL and the negotiated Vary header values, so different
+// representations of the same resource (eg. per Accept-Language or
+// Accept-Encoding) are cached separately. It sets an "X-Cache: HIT|MISS"
+// header on every response it handles.
+type Store struct {
+	// Storer is the backend used to persist cached entries
+	Storer Storer
+	// TTL is how long an entry remains valid once cached
+	TTL time.Duration
+	// Tags records the Surrogate-Key tags carried by cached entries, so
+	// Invalidate can evict them by tag instead of by TTL expiry. A nil
+	// Tags disables surrogate-key invalidation.
+	Tags TagIndex
+}
+
+// NewStore is a constructor for Store
+func NewStore(storer Storer, ttl time.Duration) *Store {
+	return &Store{Storer: storer, TTL: ttl, Tags: NewMemoryTagIndex()}
+}
+
+// Middleware caches GET and HEAD responses and serves cached entries on
+// subsequent matching requests. Requests using any other method are
+// passed through untouched.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		groupKey := varyGroupKey(r.Method, r.URL.String())
+		rawVary, _, _ := s.Storer.Get(groupKey)
+		key := requestCacheKey(r, string(rawVary))
+		if raw, ok, err := s.Storer.Get(key); err == nil && ok {
+			var entry cacheEntry
+			if err := json.Unmarshal(raw, &entry); err == nil {
+				w.Header().Set("X-Cache", "HIT")
+				writeEntry(w, &entry)
+				return
+			}
+		}
+		rec := newRecorder(w)
+		next.ServeHTTP(rec, r)
+		entry := cacheEntry{
+			Status: rec.status,
+			Header: w.Header().Clone(),
+			Body:   rec.body.Bytes(),
+		}
+		// Surrogate-Key is an internal cache-control signal, as with
+		// Varnish/Fastly, and must never reach the client or be
+		// replayed from a cached entry - only used here to tag it.
+		surrogateKey := entry.Header.Get("Surrogate-Key")
+		entry.Header.Del("Surrogate-Key")
+		w.Header().Del("Surrogate-Key")
+		if isCacheableStatus(entry.Status) {
+			// The response may carry a Vary the group slot didn't know about
+			// yet (eg. the very first request for this method+URL). Key the
+			// entry by that actual Vary rather than the stale one used for
+			// the lookup above, so the next request - which will find the
+			// group slot updated below - hits this entry immediately instead
+			// of missing once more while the group slot catches up.
+			vary := entry.Header.Get("Vary")
+			if vary != string(rawVary) {
+				key = requestCacheKey(r, vary)
+			}
+			if raw, err := json.Marshal(entry); err == nil {
+				s.Storer.Set(key, raw, s.TTL) //nolint:errcheck
+				if len(vary) > 0 || len(rawVary) > 0 {
+					s.Storer.Set(groupKey, []byte(vary), s.TTL) //nolint:errcheck
+				}
+				if s.Tags != nil && len(surrogateKey) > 0 {
+					s.Tags.Tag(key, strings.Fields(surrogateKey)) //nolint:errcheck
+				}
+			}
+		}
+		w.Header().Set("X-Cache", "MISS")
+		w.WriteHeader(entry.Status)
+		w.Write(entry.Body) //nolint:errcheck
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Purge evicts every cached entry whose key matches the given glob pattern
+func (s *Store) Purge(pattern string) error {
+	return s.Storer.Purge(pattern)
+}
+
+// PurgeHandler returns an http.HandlerFunc suitable for mounting as an
+// admin endpoint, eg. POST /_cache/purge?pattern=GET+/articles/*, that
+// evicts every cached entry whose key matches the pattern query parameter
+func (s *Store) PurgeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		pattern := r.URL.Query().Get("pattern")
+		if len(pattern) == 0 {
+			http.Error(w, "missing pattern query parameter", http.StatusBadRequest)
+			return
+		}
+		if err := s.Purge(pattern); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Invalidate evicts every cache entry carrying the given surrogate key,
+// as set on a response via the Surrogate-Key header (eg.
+// "Surrogate-Key: article-42 user-7"). It is a no-op when Tags is nil.
+func (s *Store) Invalidate(tag string) error {
+	if s.Tags == nil {
+		return nil
+	}
+	keys, err := s.Tags.Keys(tag)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.Storer.Delete(key); err != nil {
+			return err
+		}
+		if err := s.Tags.Untag(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateHandler returns an http.HandlerFunc suitable for mounting as
+// an admin endpoint, eg. POST /_cache/purge?tag=article-42, that evicts
+// every cached entry carrying the given surrogate key
+func (s *Store) InvalidateHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		tag := r.URL.Query().Get("tag")
+		if len(tag) == 0 {
+			http.Error(w, "missing tag query parameter", http.StatusBadRequest)
+			return
+		}
+		if err := s.Invalidate(tag); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeEntry replays a cached entry onto the given ResponseWriter
+func writeEntry(w http.ResponseWriter, entry *cacheEntry) {
+	for k, v := range entry.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(entry.Status)
+	w.Write(entry.Body) //nolint:errcheck
+}
+
+// cacheableStatuses are the response statuses Store will persist, the
+// default-cacheable statuses of RFC 7234 section 3. Anything else (eg. a
+// transient 500 from an upstream) is served but never written to the
+// Storer, so it can't become sticky for the rest of the TTL.
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusPartialContent:       true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+}
+
+func isCacheableStatus(status int) bool {
+	return cacheableStatuses[status]
+}
+
+// varyGroupKey identifies the storage slot holding the Vary header value
+// last seen for a given method and URL
+func varyGroupKey(method, url string) string {
+	return fmt.Sprintf("vary:%s %s", method, url)
+}
+
+// requestCacheKey builds a human-readable cache key from the request
+// method and URL plus a hash of the request header values named in the
+// vary string, so Purge patterns can still glob against method and URL
+func requestCacheKey(r *http.Request, vary string) string {
+	return fmt.Sprintf("%s %s#%s", r.Method, r.URL.String(), varyHash(r, vary))
+}
+
+// varyHash hashes the request header values named in the comma separated
+// vary string
+func varyHash(r *http.Request, vary string) string {
+	h := sha1.New() //nolint:gosec
+	for _, name := range strings.Split(vary, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		h.Write([]byte(name))
+		h.Write([]byte(r.Header.Get(name)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,74 @@
+package cache
+
+import "sync"
+
+// TagIndex records which cache keys are tagged with which surrogate keys,
+// as set by a handler via the Surrogate-Key response header (eg.
+// "Surrogate-Key: article-42 user-7"). It lets Store.Invalidate evict
+// every entry carrying a tag without requiring a glob pattern that
+// happens to match the underlying Storer's key format, a pattern
+// popularised by CDNs such as Varnish/Fastly.
+type TagIndex interface {
+	// Tag associates key with every surrogate tag in tags
+	Tag(key string, tags []string) error
+	// Keys returns every cache key tagged with tag
+	Keys(tag string) ([]string, error)
+	// Untag removes every association recorded for key, eg. once the
+	// entry it refers to has been evicted
+	Untag(key string) error
+}
+
+// MemoryTagIndex is a TagIndex backed by an in-process map. It is the
+// default tag index for Store and does not share state across replicas.
+type MemoryTagIndex struct {
+	mu      sync.RWMutex
+	tagKeys map[string]map[string]struct{}
+	keyTags map[string][]string
+}
+
+// NewMemoryTagIndex is a constructor for MemoryTagIndex
+func NewMemoryTagIndex() *MemoryTagIndex {
+	return &MemoryTagIndex{
+		tagKeys: make(map[string]map[string]struct{}),
+		keyTags: make(map[string][]string),
+	}
+}
+
+// Tag implements TagIndex
+func (m *MemoryTagIndex) Tag(key string, tags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, old := range m.keyTags[key] {
+		delete(m.tagKeys[old], key)
+	}
+	m.keyTags[key] = tags
+	for _, tag := range tags {
+		if m.tagKeys[tag] == nil {
+			m.tagKeys[tag] = make(map[string]struct{})
+		}
+		m.tagKeys[tag][key] = struct{}{}
+	}
+	return nil
+}
+
+// Keys implements TagIndex
+func (m *MemoryTagIndex) Keys(tag string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.tagKeys[tag]))
+	for k := range m.tagKeys[tag] {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Untag implements TagIndex
+func (m *MemoryTagIndex) Untag(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range m.keyTags[key] {
+		delete(m.tagKeys[tag], key)
+	}
+	delete(m.keyTags, key)
+	return nil
+}
@@ -0,0 +1,43 @@
+// Package cachegrpc is a gRPC interceptor equivalent of the cache
+// net/http middleware. It sets the same max-age/Expires values as
+// outgoing gRPC response metadata, for services that expose both a
+// net/http and a gRPC representation of a resource.
+package cachegrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dictyBase/go-middlewares/middlewares/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor sets cache-control and expires header metadata
+// derived from c before invoking the handler for every unary RPC
+func UnaryServerInterceptor(c *cache.HTTPCache) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := grpc.SetHeader(ctx, headerMD(c)); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor sets cache-control and expires header metadata
+// derived from c before invoking the handler for every streaming RPC
+func StreamServerInterceptor(c *cache.HTTPCache) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := ss.SetHeader(headerMD(c)); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func headerMD(c *cache.HTTPCache) metadata.MD {
+	return metadata.Pairs(
+		"cache-control", fmt.Sprintf("public, max-age=%d", c.MaxAge),
+		"expires", c.Expires,
+	)
+}
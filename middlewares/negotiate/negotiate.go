@@ -0,0 +1,290 @@
+// Package negotiate implements HTTP content negotiation as described in
+// RFC 7231 section 5.3. It parses the Accept and Content-Type headers,
+// including media type parameters and q-values, and matches them against
+// a caller supplied list of acceptable media types. This replaces naive
+// string-equality checks against a single quoted media type, which reject
+// perfectly valid headers such as
+// `application/vnd.api+json; supported-ext="dictybase/filtering-resouce", */*`
+// or ones with reordered parameters or q-values.
+package negotiate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/manyminds/api2go"
+)
+
+type contextKey string
+
+// String outputs the details of context key
+func (c contextKey) String() string {
+	return "negotiate context key " + string(c)
+}
+
+// ContextKeyMediaType is the key used for storing the negotiated
+// MediaType in the request context
+var ContextKeyMediaType = contextKey("mediatype")
+
+// MediaType represents a parsed media type together with its parameters
+// and q-value, as found in an Accept or Content-Type header
+type MediaType struct {
+	Type    string
+	Subtype string
+	Params  map[string]string
+	Q       float64
+}
+
+// String reassembles the media type into its canonical "type/subtype"
+// form, without parameters
+func (m MediaType) String() string {
+	return m.Type + "/" + m.Subtype
+}
+
+// Matches reports whether m satisfies the given acceptable media type,
+// honoring the "*/*" and "type/*" wildcards on either side and, when the
+// acceptable type carries parameters, requiring each of them to also
+// appear in m
+func (m MediaType) Matches(acceptable string) bool {
+	amt, err := ParseMediaType(acceptable)
+	if err != nil {
+		return false
+	}
+	if amt.Type != "*" && m.Type != "*" && amt.Type != m.Type {
+		return false
+	}
+	if amt.Subtype != "*" && m.Subtype != "*" && amt.Subtype != m.Subtype {
+		return false
+	}
+	for k, v := range amt.Params {
+		if m.Params[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseMediaType parses a single media type, eg.
+// `application/vnd.api+json; supported-ext="dictybase/filtering-resouce"`,
+// into its type, subtype, parameters and q-value, which defaults to 1 when
+// absent
+func ParseMediaType(s string) (MediaType, error) {
+	mt, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+	if err != nil {
+		return MediaType{}, err
+	}
+	parts := strings.SplitN(mt, "/", 2)
+	if len(parts) != 2 {
+		return MediaType{}, fmt.Errorf("negotiate: invalid media type %q", s)
+	}
+	q := 1.0
+	if v, ok := params["q"]; ok {
+		delete(params, "q")
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			q = f
+		}
+	}
+	return MediaType{Type: parts[0], Subtype: parts[1], Params: params, Q: q}, nil
+}
+
+// ParseAccept parses the comma separated media ranges of an Accept (or
+// Accept-like) header, ordering them from most to least preferred, ie. by
+// descending q-value and then by specificity as described in RFC 7231
+// section 5.3.2
+func ParseAccept(header string) ([]MediaType, error) {
+	var types []MediaType
+	for _, tok := range splitMediaTypes(header) {
+		tok = strings.TrimSpace(tok)
+		if len(tok) == 0 {
+			continue
+		}
+		mt, err := ParseMediaType(tok)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, mt)
+	}
+	sort.SliceStable(types, func(i, j int) bool {
+		if types[i].Q != types[j].Q {
+			return types[i].Q > types[j].Q
+		}
+		return specificity(types[i]) > specificity(types[j])
+	})
+	return types, nil
+}
+
+// Best returns the highest priority parsed media type from an already
+// ordered Accept list that matches one of the acceptable media types,
+// along with the acceptable entry it matched. ok is false when nothing
+// matches, including when the client explicitly disallowed every
+// candidate with a q-value of 0.
+func Best(accept []MediaType, acceptable []string) (mt MediaType, matched string, ok bool) {
+	for _, candidate := range accept {
+		if candidate.Q == 0 {
+			continue
+		}
+		for _, a := range acceptable {
+			if candidate.Matches(a) {
+				return candidate, a, true
+			}
+		}
+	}
+	return MediaType{}, "", false
+}
+
+// specificity scores a media type by how narrowly it was specified, so
+// that, for equal q-values, "text/html" outranks "text/*" which outranks
+// "*/*"
+func specificity(m MediaType) int {
+	score := 0
+	if m.Type != "*" {
+		score++
+	}
+	if m.Subtype != "*" {
+		score++
+	}
+	return score + len(m.Params)
+}
+
+// splitMediaTypes splits a header value on commas that are not inside a
+// quoted parameter value
+func splitMediaTypes(header string) []string {
+	var tokens []string
+	var buf strings.Builder
+	inQuotes := false
+	for _, r := range header {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			buf.WriteRune(r)
+		case r == ',' && !inQuotes:
+			tokens = append(tokens, buf.String())
+			buf.Reset()
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	if buf.Len() > 0 {
+		tokens = append(tokens, buf.String())
+	}
+	return tokens
+}
+
+// Middleware returns an http middleware that negotiates the request's
+// Accept header against the given acceptable media types (most preferred
+// listed first on ties) and stores the match in the request context under
+// ContextKeyMediaType. It terminates the chain with 406 Not Acceptable
+// when the Accept header can't be satisfied, and with 415 Unsupported
+// Media Type when the request carries a Content-Type that isn't also one
+// of the acceptable media types.
+func Middleware(acceptable ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			accept := r.Header.Get("Accept")
+			if len(accept) == 0 {
+				accept = "*/*"
+			}
+			parsed, err := ParseAccept(accept)
+			if err != nil {
+				negotiationError(w, http.StatusNotAcceptable, "Accept header is not acceptable", err.Error())
+				return
+			}
+			mt, _, ok := Best(parsed, acceptable)
+			if !ok {
+				negotiationError(
+					w,
+					http.StatusNotAcceptable,
+					"Accept header is not acceptable",
+					fmt.Sprintf("The given Accept header value %s does not match any of %v", accept, acceptable),
+				)
+				return
+			}
+			if ct := r.Header.Get("Content-Type"); len(ct) > 0 {
+				cmt, err := ParseMediaType(ct)
+				if err != nil || !matchesAny(cmt, acceptable) {
+					negotiationError(
+						w,
+						http.StatusUnsupportedMediaType,
+						"Media type is not supported",
+						fmt.Sprintf("The given media type %s in Content-Type header is not supported", ct),
+					)
+					return
+				}
+			}
+			ctx := context.WithValue(r.Context(), ContextKeyMediaType, mt)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Handlers maps an acceptable media type string to the http.HandlerFunc
+// that should serve it
+type Handlers map[string]http.HandlerFunc
+
+// Dispatch negotiates the request's Accept header against the media
+// types registered in handlers and invokes the matching one, storing the
+// match in the request context under ContextKeyMediaType. It responds
+// 406 Not Acceptable when no registered handler satisfies the Accept
+// header.
+func Dispatch(handlers Handlers) http.HandlerFunc {
+	acceptable := make([]string, 0, len(handlers))
+	for k := range handlers {
+		acceptable = append(acceptable, k)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		if len(accept) == 0 {
+			accept = "*/*"
+		}
+		parsed, err := ParseAccept(accept)
+		if err != nil {
+			negotiationError(w, http.StatusNotAcceptable, "Accept header is not acceptable", err.Error())
+			return
+		}
+		mt, matched, ok := Best(parsed, acceptable)
+		if !ok {
+			negotiationError(
+				w,
+				http.StatusNotAcceptable,
+				"Accept header is not acceptable",
+				fmt.Sprintf("The given Accept header value %s does not match any registered handler", accept),
+			)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ContextKeyMediaType, mt)
+		handlers[matched](w, r.WithContext(ctx))
+	}
+}
+
+func matchesAny(mt MediaType, acceptable []string) bool {
+	for _, a := range acceptable {
+		if mt.Matches(a) {
+			return true
+		}
+	}
+	return false
+}
+
+func negotiationError(w http.ResponseWriter, status int, title, detail string) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	jsnErr := api2go.Error{
+		Status: strconv.Itoa(status),
+		Title:  title,
+		Detail: detail,
+		Meta: map[string]interface{}{
+			"creator": "negotiate middleware",
+		},
+	}
+	err := json.NewEncoder(w).Encode(api2go.HTTPError{Errors: []api2go.Error{jsnErr}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
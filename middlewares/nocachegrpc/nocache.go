@@ -0,0 +1,43 @@
+// Package nocachegrpc is a gRPC interceptor equivalent of the nocache
+// net/http middleware. It injects the same no-cache header values as
+// outgoing gRPC response metadata, for services that expose both a
+// net/http and a gRPC representation of a resource.
+package nocachegrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// noCacheHeaders mirrors nocache.noCacheHeaders. gRPC metadata keys are
+// lowercased, unlike the canonical HTTP header names.
+var noCacheHeaders = metadata.Pairs(
+	"expires", "Thu, 01 Jan 1970 00:00:00 UTC",
+	"cache-control", "no-cache, no-store, no-transform, must-revalidate, private, max-age=0",
+	"pragma", "no-cache",
+	"x-accel-expires", "0",
+)
+
+// UnaryServerInterceptor sets the no-cache header metadata before
+// invoking the handler for every unary RPC
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := grpc.SetHeader(ctx, noCacheHeaders); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor sets the no-cache header metadata before
+// invoking the handler for every streaming RPC
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := ss.SetHeader(noCacheHeaders); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
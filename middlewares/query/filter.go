@@ -0,0 +1,116 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FilterOperator identifies the comparison applied by a structured Filter
+type FilterOperator string
+
+// Supported filter operators
+const (
+	OpEq       FilterOperator = "eq"
+	OpNe       FilterOperator = "ne"
+	OpGt       FilterOperator = "gt"
+	OpGte      FilterOperator = "gte"
+	OpLt       FilterOperator = "lt"
+	OpLte      FilterOperator = "lte"
+	OpIn       FilterOperator = "in"
+	OpNotIn    FilterOperator = "notin"
+	OpLike     FilterOperator = "like"
+	OpContains FilterOperator = "contains"
+)
+
+// multiValueOperators split their value on "," into multiple Values
+var multiValueOperators = map[FilterOperator]bool{
+	OpIn:    true,
+	OpNotIn: true,
+}
+
+// validOperators are the operators accepted in the bracketed
+// filter[field][operator] form
+var validOperators = map[FilterOperator]bool{
+	OpEq:       true,
+	OpNe:       true,
+	OpGt:       true,
+	OpGte:      true,
+	OpLt:       true,
+	OpLte:      true,
+	OpIn:       true,
+	OpNotIn:    true,
+	OpLike:     true,
+	OpContains: true,
+}
+
+// Filter is a single structured filter expression, eg. filter[age][gte]=18
+// parses into Filter{Field: "age", Operator: OpGte, Values: []string{"18"}}
+type Filter struct {
+	Field    string
+	Operator FilterOperator
+	Values   []string
+}
+
+var (
+	filterRegx        = regexp.MustCompile(`^filter\[(\w+)\](?:\[(\w+)\])?$`)
+	compactFilterRegx = regexp.MustCompile(`^(\w+)(==|!=|>=|<=|>|<)(.*)$`)
+	compactOperators  = map[string]FilterOperator{
+		"==": OpEq,
+		"!=": OpNe,
+		">=": OpGte,
+		"<=": OpLte,
+		">":  OpGt,
+		"<":  OpLt,
+	}
+)
+
+// addFilter appends a structured Filter and, for backward compatibility,
+// also populates the deprecated Filters map for equality filters
+func (p *Params) addFilter(field string, op FilterOperator, value string) {
+	values := []string{value}
+	if multiValueOperators[op] {
+		values = strings.Split(value, ",")
+	}
+	p.FilterExprs = append(p.FilterExprs, Filter{Field: field, Operator: op, Values: values})
+	if op == OpEq {
+		p.Filters[field] = value
+	}
+	p.HasFilters = true
+}
+
+// parseBracketFilter parses the bracketed filter[field] or
+// filter[field][operator] query parameter form
+func (p *Params) parseBracketFilter(key, value string) error {
+	m := filterRegx.FindStringSubmatch(key)
+	if m == nil {
+		return fmt.Errorf("unable to match filter query param %s", key)
+	}
+	op := FilterOperator(m[2])
+	if len(op) == 0 {
+		op = OpEq
+	}
+	if !validOperators[op] {
+		return fmt.Errorf("unsupported filter operator %s", op)
+	}
+	p.addFilter(m[1], op, value)
+	return nil
+}
+
+// parseCompactFilter parses the compact filter grammar,
+// filter=field<op>value;field2<op>value2, where <op> is one of
+// ==, !=, >=, <=, >, <
+func (p *Params) parseCompactFilter(value string) error {
+	for _, expr := range strings.Split(value, ";") {
+		expr = strings.TrimSpace(expr)
+		if len(expr) == 0 {
+			continue
+		}
+		m := compactFilterRegx.FindStringSubmatch(expr)
+		if m == nil {
+			return fmt.Errorf("unable to parse filter expression %s", expr)
+		}
+		p.addFilter(m[1], compactOperators[m[2]], m[3])
+	}
+	return nil
+}
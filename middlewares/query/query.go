@@ -3,7 +3,10 @@
 //		include - /url?include=foo,bar,baz
 //		fields(sparse fieldsets) - /url?fields[articles]=title,body&fields[people]=name
 //		filter - /url?filter[name]=foo&filter[country]=argentina
-// The include and fields are part of JSON API whereas filter is a custom
+//		page(pagination) - /url?page[number]=2&page[size]=10 or /url?page[offset]=20&page[limit]=10
+//		sort - /url?sort=-created,name
+// The include, fields, page and sort parameters are part of JSON API
+// whereas filter is a custom
 // extension for dictybase. For details look here
 // https://github.com/json-api/json-api/blob/9c7a03dbc37f80f6ca81b16d444c960e96dd7a57/extensions/index.md#-extension-negotiation
 // and here
@@ -17,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -41,20 +45,51 @@ var (
 	qregx                 = regexp.MustCompile(`^\w+\[(\w+)\]$`)
 )
 
+// Pagination holds the JSON API page[...] query parameters. Number/Size
+// follow the page-based pagination strategy, Offset/Limit the
+// offset-based one; callers typically only populate one of the two pairs.
+type Pagination struct {
+	Number int
+	Size   int
+	Offset int
+	Limit  int
+}
+
+// SortField is a single field from a JSON API sort query parameter, eg.
+// the "-created" in sort=-created,name
+type SortField struct {
+	// Field is the name of the field to sort on
+	Field string
+	// Desc is true when the field was prefixed with "-"
+	Desc bool
+}
+
 // Params is container for various query parameters
 type Params struct {
 	// contain include query paramters
 	Includes []string
 	// contain fields query paramters
 	Fields map[string][]string
-	// contain filter query parameters
+	// Filters is kept populated for equality filters only.
+	//
+	// Deprecated: use FilterExprs, which also captures the comparison
+	// operator and supports multi-valued operators like "in"/"notin".
 	Filters map[string]string
+	// FilterExprs contain the structured filter query parameters,
+	// supporting operators beyond plain equality
+	FilterExprs []Filter
+	// contain page query parameters
+	Pagination Pagination
+	// contain sort query parameters, in the order they were given
+	Sort []SortField
 	// check for presence of fields parameters
 	HasFields bool
 	// check for presence of include parameters
 	HasIncludes bool
 	// check for presence of filter parameters
 	HasFilters bool
+	// check for presence of page parameters
+	HasPagination bool
 }
 
 func newParams() *Params {
@@ -71,64 +106,32 @@ func newParams() *Params {
 // https://github.com/dictyBase/Migration/blob/master/Webservice-specs.md#dictybase-specifications.
 // Otherwise, the request never gets passed to the handler and either of
 // 406(Not Acceptable) or 415(Unsupported Media Type) http status is returned.
+// Filters are parsed with the bracketed grammar, filter[field][op]=value;
+// use MiddlewareFnWithOptions to also accept the compact string grammar.
 func MiddlewareFn(fn http.HandlerFunc) http.HandlerFunc {
+	return MiddlewareFnWithOptions(fn, false)
+}
+
+// MiddlewareFnWithOptions is the configurable form of MiddlewareFn. When
+// compactFilter is true, a bare "filter" query parameter is parsed with
+// the compact grammar filter=field<op>value;field2<op>value2 (supported
+// operators: ==, !=, >=, <=, >, <) in addition to the bracketed
+// filter[field][op]=value form.
+func MiddlewareFnWithOptions(fn http.HandlerFunc, compactFilter bool) http.HandlerFunc {
 	newFn := func(w http.ResponseWriter, r *http.Request) {
-		params := newParams()
 		values := r.URL.Query()
-		for k, v := range values {
-			switch {
-			case strings.HasPrefix(k, "filter"):
-				// check for correct header
-				if !validateHeader(w, r) {
-					return
-				}
-				if m := qregx.FindStringSubmatch(k); m != nil {
-					params.Filters[m[1]] = v[0]
-					if !params.HasFilters {
-						params.HasFilters = true
-					}
-				} else {
-					queryParamError(
-						w,
-						http.StatusBadRequest,
-						"Invalid query parameter",
-						fmt.Sprintf("Unable to match filter query param %s", v[0]),
-					)
-					return
-				}
-			case strings.HasPrefix(k, "fields"):
-				if m := qregx.FindStringSubmatch(k); m != nil {
-					if strings.Contains(v[0], ",") {
-						params.Fields[m[1]] = strings.Split(v[0], ",")
-					} else {
-						params.Fields[m[1]] = []string{v[0]}
-					}
-					if !params.HasFields {
-						params.HasFields = true
-					}
-				} else {
-					queryParamError(
-						w,
-						http.StatusBadRequest,
-						"Invalid query parameter",
-						fmt.Sprintf("Unable to match fields query param %s", v[0]),
-					)
-					return
-				}
-			case k == "include":
-				if strings.Contains(v[0], ",") {
-					params.Includes = strings.Split(v[0], ",")
-				} else {
-					params.Includes = []string{v[0]}
-				}
-				if !params.HasIncludes {
-					params.HasIncludes = true
-				}
-			default:
-				continue
+		for k := range values {
+			if strings.HasPrefix(k, "filter") && !validateHeader(w, r) {
+				return
 			}
 		}
-		if params.HasFilters || params.HasFields || params.HasIncludes {
+		params, err := ParseValues(values, compactFilter)
+		if err != nil {
+			queryParamError(w, http.StatusBadRequest, "Invalid query parameter", err.Error())
+			return
+		}
+		if params.HasFilters || params.HasFields || params.HasIncludes ||
+			params.HasPagination || len(params.Sort) > 0 {
 			ctx := context.WithValue(r.Context(), ContextKeyQueryParams, params)
 			fn(w, r.WithContext(ctx))
 		} else {
@@ -138,6 +141,66 @@ func MiddlewareFn(fn http.HandlerFunc) http.HandlerFunc {
 	return newFn
 }
 
+// ParseValues parses a JSON:API-shaped set of query values (include,
+// fields[...], filter[...]/filter, page[...] and sort) into a Params,
+// independent of any transport. MiddlewareFnWithOptions builds on this
+// for net/http requests; the same parsing is reused by querygrpc for
+// gRPC requests carrying the query string as metadata, so both
+// transports share one filter/sort/pagination struct. Unlike
+// MiddlewareFnWithOptions it does not validate the Accept/Content-Type
+// headers required for the filter query extension, since that check is
+// net/http specific.
+func ParseValues(values url.Values, compactFilter bool) (*Params, error) {
+	params := newParams()
+	for k, v := range values {
+		switch {
+		case strings.HasPrefix(k, "filter"):
+			var err error
+			if k == "filter" && compactFilter {
+				err = params.parseCompactFilter(v[0])
+			} else {
+				err = params.parseBracketFilter(k, v[0])
+			}
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(k, "fields"):
+			m := qregx.FindStringSubmatch(k)
+			if m == nil {
+				return nil, fmt.Errorf("unable to match fields query param %s", v[0])
+			}
+			if strings.Contains(v[0], ",") {
+				params.Fields[m[1]] = strings.Split(v[0], ",")
+			} else {
+				params.Fields[m[1]] = []string{v[0]}
+			}
+			if !params.HasFields {
+				params.HasFields = true
+			}
+		case k == "include":
+			if strings.Contains(v[0], ",") {
+				params.Includes = strings.Split(v[0], ",")
+			} else {
+				params.Includes = []string{v[0]}
+			}
+			if !params.HasIncludes {
+				params.HasIncludes = true
+			}
+		case strings.HasPrefix(k, "page"):
+			if err := params.parsePage(k, v[0]); err != nil {
+				return nil, err
+			}
+		case k == "sort":
+			if err := params.parseSort(v[0]); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+	}
+	return params, nil
+}
+
 func queryParamError(w http.ResponseWriter, status int, title, detail string) {
 	w.Header().Set("Content-Type", "application/vnd.api+json")
 	w.WriteHeader(status)
@@ -155,6 +218,93 @@ func queryParamError(w http.ResponseWriter, status int, title, detail string) {
 	}
 }
 
+// parsePage parses a single page[...] query parameter, eg. page[number]=2,
+// validating that its value is a non-negative integer
+func (p *Params) parsePage(key, value string) error {
+	m := qregx.FindStringSubmatch(key)
+	if m == nil {
+		return fmt.Errorf("unable to match page query param %s", key)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("page[%s] must be a non-negative integer, got %s", m[1], value)
+	}
+	switch m[1] {
+	case "number":
+		p.Pagination.Number = n
+	case "size":
+		p.Pagination.Size = n
+	case "offset":
+		p.Pagination.Offset = n
+	case "limit":
+		p.Pagination.Limit = n
+	default:
+		return fmt.Errorf("unsupported page query parameter %s", key)
+	}
+	p.HasPagination = true
+	return nil
+}
+
+// sortFieldRegx restricts sort field names to word characters, so a
+// field can never carry SQL syntax through to SQLOrderBy
+var sortFieldRegx = regexp.MustCompile(`^\w+$`)
+
+// parseSort parses a comma separated sort query parameter, eg.
+// sort=-created,name, into Sort fields, a leading "-" marking a field as
+// descending. Field names that aren't a plain word (eg. containing
+// whitespace or punctuation) are rejected, since SQLOrderBy interpolates
+// them directly into a SQL fragment.
+func (p *Params) parseSort(value string) error {
+	for _, f := range strings.Split(value, ",") {
+		f = strings.TrimSpace(f)
+		if len(f) == 0 {
+			continue
+		}
+		field := strings.TrimPrefix(f, "-")
+		if !sortFieldRegx.MatchString(field) {
+			return fmt.Errorf("sort field %q must match %s", field, sortFieldRegx.String())
+		}
+		p.Sort = append(p.Sort, SortField{
+			Field: field,
+			Desc:  strings.HasPrefix(f, "-"),
+		})
+	}
+	return nil
+}
+
+// SQLOrderBy renders the parsed sort fields as a SQL ORDER BY clause
+// fragment, eg. "created DESC, name ASC". It returns an empty string when
+// no sort fields were parsed. Field names are restricted to \w+ by
+// parseSort, so the returned fragment is safe to interpolate directly.
+func (p *Params) SQLOrderBy() string {
+	if len(p.Sort) == 0 {
+		return ""
+	}
+	parts := make([]string, len(p.Sort))
+	for i, s := range p.Sort {
+		dir := "ASC"
+		if s.Desc {
+			dir = "DESC"
+		}
+		parts[i] = fmt.Sprintf("%s %s", s.Field, dir)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LimitOffset returns the limit and offset to use for a datastore query.
+// page[offset]/page[limit], when given, are used directly; otherwise they
+// are derived from page[number]/page[size].
+func (p *Params) LimitOffset() (limit int, offset int) {
+	if p.Pagination.Limit > 0 || p.Pagination.Offset > 0 {
+		return p.Pagination.Limit, p.Pagination.Offset
+	}
+	limit = p.Pagination.Size
+	if p.Pagination.Number > 1 {
+		offset = (p.Pagination.Number - 1) * p.Pagination.Size
+	}
+	return limit, offset
+}
+
 func validateHeader(w http.ResponseWriter, r *http.Request) bool {
 	if r.Header.Get(acceptH) != filterMediaType {
 		queryParamError(
@@ -0,0 +1,78 @@
+// Package querygrpc is a gRPC interceptor equivalent of the query
+// net/http middleware. It parses a JSON:API-shaped query string out of
+// an incoming request's metadata and stores the resulting
+// *query.Params in the context under query.ContextKeyQueryParams, so
+// handlers shared between the net/http and gRPC transports read query
+// parameters the same way regardless of which one served the request.
+package querygrpc
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/dictyBase/go-middlewares/middlewares/query"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata key carrying the JSON:API-shaped query
+// string, eg. "include=author&page[number]=2&sort=-created". An
+// HTTP/JSON transcoding gateway should forward the original request's
+// raw query string under this key.
+const MetadataKey = "x-jsonapi-query"
+
+// UnaryServerInterceptor parses the query string found in incoming
+// metadata under MetadataKey and stores the resulting *query.Params in
+// the context under query.ContextKeyQueryParams before invoking the
+// handler. compactFilter enables the compact filter grammar, as in
+// query.MiddlewareFnWithOptions. A request without MetadataKey, or with
+// an unparseable query string, reaches the handler unmodified.
+func UnaryServerInterceptor(compactFilter bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withQueryParams(ctx, compactFilter), req)
+	}
+}
+
+// StreamServerInterceptor is the streaming RPC equivalent of
+// UnaryServerInterceptor
+func StreamServerInterceptor(compactFilter bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &wrappedServerStream{
+			ServerStream: ss,
+			ctx:          withQueryParams(ss.Context(), compactFilter),
+		})
+	}
+}
+
+// withQueryParams parses MetadataKey out of ctx's incoming metadata, if
+// present, and returns a context carrying the resulting *query.Params
+func withQueryParams(ctx context.Context, compactFilter bool) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	raw := md.Get(MetadataKey)
+	if len(raw) == 0 {
+		return ctx
+	}
+	values, err := url.ParseQuery(raw[0])
+	if err != nil {
+		return ctx
+	}
+	params, err := query.ParseValues(values, compactFilter)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, query.ContextKeyQueryParams, params)
+}
+
+// wrappedServerStream overrides grpc.ServerStream's Context so a
+// replacement context can be threaded through to the stream handler
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedServerStream) Context() context.Context {
+	return w.ctx
+}